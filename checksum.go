@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// checksumSpec is the checksum a completed download is expected to match.
+// An empty Expected means no verification is requested.
+type checksumSpec struct {
+	Algo     string
+	Expected string
+}
+
+// newHasher returns a hash.Hash for the named algorithm. An empty name
+// defaults to sha256.
+func newHasher(algo string) (hash.Hash, error) {
+	switch strings.ToLower(algo) {
+	case "", "sha256":
+		return sha256.New(), nil
+	case "md5":
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+}
+
+// computeFileChecksum hashes the file at path with the named algorithm.
+func computeFileChecksum(path, algo string) (string, error) {
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checksumFragment extracts a "#sha256=..." fragment from a URL, if present.
+func checksumFragment(rawURL string) checksumSpec {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Fragment == "" {
+		return checksumSpec{}
+	}
+
+	const prefix = "sha256="
+	if strings.HasPrefix(u.Fragment, prefix) {
+		return checksumSpec{Algo: "sha256", Expected: strings.TrimPrefix(u.Fragment, prefix)}
+	}
+
+	return checksumSpec{}
+}
+
+// loadChecksumFile parses a sha256sum(1)-style sidecar file ("HASH  filename"
+// per line, with an optional "*" binary-mode marker before the filename)
+// into a map from filename to expected hash.
+func loadChecksumFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading checksum file %s: %w", path, err)
+	}
+
+	sums := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		sums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+	}
+
+	return sums, nil
+}
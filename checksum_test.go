@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComputeFileChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.bin")
+	if err := os.WriteFile(path, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const wantSHA256 = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	got, err := computeFileChecksum(path, "sha256")
+	if err != nil {
+		t.Fatalf("computeFileChecksum: %v", err)
+	}
+	if got != wantSHA256 {
+		t.Errorf("computeFileChecksum(sha256) = %s, want %s", got, wantSHA256)
+	}
+
+	const wantMD5 = "5eb63bbbe01eeed093cb22bb8f5acdc3"
+	got, err = computeFileChecksum(path, "md5")
+	if err != nil {
+		t.Fatalf("computeFileChecksum: %v", err)
+	}
+	if got != wantMD5 {
+		t.Errorf("computeFileChecksum(md5) = %s, want %s", got, wantMD5)
+	}
+
+	if _, err := computeFileChecksum(path, "crc32"); err == nil {
+		t.Error("computeFileChecksum with an unsupported algorithm should error")
+	}
+}
+
+func TestChecksumFragment(t *testing.T) {
+	tests := []struct {
+		url  string
+		want checksumSpec
+	}{
+		{"http://example.com/a.bin#sha256=abc123", checksumSpec{Algo: "sha256", Expected: "abc123"}},
+		{"http://example.com/a.bin", checksumSpec{}},
+		{"http://example.com/a.bin#md5=abc123", checksumSpec{}}, // only sha256 fragments are recognized
+		{"http://example.com/a.bin?x=1#sha256=def456", checksumSpec{Algo: "sha256", Expected: "def456"}},
+	}
+
+	for _, tt := range tests {
+		got := checksumFragment(tt.url)
+		if got != tt.want {
+			t.Errorf("checksumFragment(%q) = %+v, want %+v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestLoadChecksumFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "SHA256SUMS")
+	contents := "" +
+		"# comment line, ignored\n" +
+		"\n" +
+		"aaaa111  a.bin\n" +
+		"bbbb222 *b.bin\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadChecksumFile(path)
+	if err != nil {
+		t.Fatalf("loadChecksumFile: %v", err)
+	}
+
+	want := map[string]string{"a.bin": "aaaa111", "b.bin": "bbbb222"}
+	if len(got) != len(want) {
+		t.Fatalf("loadChecksumFile = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("loadChecksumFile[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
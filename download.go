@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// requestOptions bundles the per-request customizations a download may
+// need: the User-Agent, an optional Referer, and arbitrary extra headers
+// (as used by manifest targets).
+type requestOptions struct {
+	userAgent string
+	referer   string
+	headers   map[string]string
+}
+
+// apply sets req's headers according to o.
+func (o requestOptions) apply(req *http.Request) {
+	if o.userAgent != "" {
+		req.Header.Set("User-Agent", o.userAgent)
+	}
+	if o.referer != "" {
+		req.Header.Set("Referer", o.referer)
+	}
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+}
+
+// downloadWithRetry downloads url to filename, retrying up to maxTries times
+// with an exponential backoff (starting at baseWait seconds) between
+// attempts. It resumes from wherever a previous attempt left off, via
+// downloadOnce's partial-file handling.
+//
+// connSem bounds the total number of HTTP connections this download (and any
+// others sharing the same connSem) may have open at once; it's only
+// consulted when numSegments > 1.
+//
+// If checksum.Expected is set, the completed file is verified against it and
+// deleted on mismatch, which is then reported (and retried) like any other
+// download error.
+func downloadWithRetry(httpClient *http.Client, connSem chan struct{}, url, filename string, opts requestOptions, maxTries, baseWait, numSegments int, prog *downloadProgress, limiter *tokenBucketLimiter, checksum checksumSpec) error {
+	var err error
+
+	for attempt := 0; attempt < maxTries; attempt++ {
+		if attempt > 0 {
+			wait := baseWait * (1 << uint(attempt-1))
+			fmt.Fprintf(os.Stderr, "retrying %s in %ds (attempt %d/%d): %v\n", url, wait, attempt+1, maxTries, err)
+			time.Sleep(time.Duration(wait) * time.Second)
+		}
+
+		err = downloadOnce(httpClient, connSem, url, filename, opts, numSegments, prog, limiter, checksum)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// downloadOnce performs a single download attempt of url into filename.
+//
+// If numSegments > 1 and the server advertises range support with a known
+// Content-Length, the file is fetched as numSegments concurrent range
+// requests. Otherwise it falls back to the single-stream path, which
+// supports resuming from a partial file left by a previous attempt.
+func downloadOnce(httpClient *http.Client, connSem chan struct{}, url, filename string, opts requestOptions, numSegments int, prog *downloadProgress, limiter *tokenBucketLimiter, checksum checksumSpec) error {
+	if numSegments > 1 {
+		size, rangesSupported, err := probeRangeSupport(httpClient, url, opts)
+		if err == nil && rangesSupported {
+			prog.SetTotal(size)
+			return downloadSegmented(httpClient, connSem, url, filename, opts, size, numSegments, prog, limiter, checksum)
+		}
+	}
+
+	return downloadSingleStream(httpClient, url, filename, opts, prog, limiter, checksum)
+}
+
+// probeRangeSupport finds out the full size of url and whether the server
+// supports byte-range requests for it, via a HEAD request.
+func probeRangeSupport(httpClient *http.Client, url string, opts requestOptions) (size int64, rangesSupported bool, err error) {
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("creating HEAD request: %w", err)
+	}
+
+	opts.apply(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("performing HEAD request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, nil
+	}
+
+	return resp.ContentLength, resp.Header.Get("Accept-Ranges") == "bytes" && resp.ContentLength > 0, nil
+}
+
+// downloadSingleStream downloads url into filename as a single HTTP stream.
+//
+// If a partial file from a previous attempt (filename+partSuffix) already
+// exists, it resumes the download with a Range request starting at the
+// partial file's current size. The partial file is only renamed to its
+// final name once the transfer completes successfully.
+//
+// If checksum.Expected is set, the download is verified before the rename.
+// A fresh (non-resumed) download is hashed as it's written, via an
+// io.TeeReader, so verification costs no extra pass over the file; a resumed
+// download is hashed in a separate pass afterwards, since the bytes carried
+// over from a previous attempt were never seen by this one.
+func downloadSingleStream(httpClient *http.Client, url, filename string, opts requestOptions, prog *downloadProgress, limiter *tokenBucketLimiter, checksum checksumSpec) error {
+	partName := filename + partSuffix
+
+	var offset int64
+	if info, err := os.Stat(partName); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	opts.apply(req)
+
+	resuming := offset > 0
+	if resuming {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resuming && resp.StatusCode == http.StatusPartialContent:
+		if err := checkContentRange(resp, offset); err != nil {
+			// Server's partial content doesn't line up with what we have;
+			// discard the partial file so the next attempt starts over from
+			// scratch.
+			os.Remove(partName)
+			return fmt.Errorf("cannot resume %s: %w", url, err)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// Either we weren't resuming, or the server doesn't support ranges
+		// and sent the whole file back (200); either way, start from zero.
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("unexpected response for %s: %s", url, resp.Status)
+	}
+
+	if resp.ContentLength > 0 {
+		prog.SetTotal(offset + resp.ContentLength)
+	}
+	atomic.StoreInt64(&prog.done, offset)
+
+	f, err := os.OpenFile(partName, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", partName, err)
+	}
+	defer f.Close()
+
+	var hasher hash.Hash
+	freshDownload := checksum.Expected != "" && offset == 0
+	if freshDownload {
+		hasher, err = newHasher(checksum.Algo)
+		if err != nil {
+			return err
+		}
+	}
+
+	body := io.Reader(&countingReader{Reader: throttle(resp.Body, limiter), counter: &prog.done})
+	if hasher != nil {
+		body = io.TeeReader(body, hasher)
+	}
+
+	if _, err := io.Copy(f, body); err != nil {
+		return fmt.Errorf("copying body to %s: %w", partName, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", partName, err)
+	}
+
+	if checksum.Expected != "" {
+		digest := ""
+		if hasher != nil {
+			digest = hex.EncodeToString(hasher.Sum(nil))
+		} else {
+			digest, err = computeFileChecksum(partName, checksum.Algo)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !strings.EqualFold(digest, checksum.Expected) {
+			os.Remove(partName)
+			return fmt.Errorf("checksum mismatch for %s: got %s, expected %s", url, digest, checksum.Expected)
+		}
+	}
+
+	if err := os.Rename(partName, filename); err != nil {
+		return fmt.Errorf("renaming %s to %s: %w", partName, filename, err)
+	}
+
+	return nil
+}
+
+// checkContentRange verifies that a 206 response's Content-Range header
+// reports a range that actually starts at offset, so a resumed download
+// doesn't silently splice together mismatched data.
+func checkContentRange(resp *http.Response, offset int64) error {
+	contentRange := resp.Header.Get("Content-Range")
+	if contentRange == "" {
+		return fmt.Errorf("server sent 206 without a Content-Range header")
+	}
+
+	var start int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-", &start); err != nil {
+		return fmt.Errorf("unparseable Content-Range %q: %w", contentRange, err)
+	}
+
+	if start != offset {
+		return fmt.Errorf("Content-Range starts at %d, expected %d", start, offset)
+	}
+
+	return nil
+}
+
+// segmentRange is the closed byte range [start, end] (both inclusive,
+// matching HTTP Range semantics) assigned to one segment.
+type segmentRange struct {
+	start, end int64
+}
+
+// splitIntoSegments divides a file of the given size into at most
+// numSegments contiguous byte ranges of roughly equal length.
+func splitIntoSegments(size int64, numSegments int) []segmentRange {
+	if int64(numSegments) > size {
+		numSegments = int(size)
+	}
+	if numSegments < 1 {
+		numSegments = 1
+	}
+
+	chunkSize := size / int64(numSegments)
+	ranges := make([]segmentRange, 0, numSegments)
+
+	start := int64(0)
+	for i := 0; i < numSegments; i++ {
+		end := start + chunkSize - 1
+		if i == numSegments-1 {
+			end = size - 1
+		}
+		ranges = append(ranges, segmentRange{start: start, end: end})
+		start = end + 1
+	}
+
+	return ranges
+}
+
+// downloadSegmented fetches url in numSegments concurrent range requests,
+// writing each segment directly into its slot of a pre-allocated
+// destination file. connSem bounds how many of these segment requests (plus
+// any other segmented downloads sharing it) may be in flight at once.
+//
+// If checksum.Expected is set, the assembled file is hashed in a single pass
+// once every segment has landed; segments arrive out of order and from
+// separate connections, so there's no single stream to tee a hash from the
+// way the single-stream path does.
+func downloadSegmented(httpClient *http.Client, connSem chan struct{}, url, filename string, opts requestOptions, size int64, numSegments int, prog *downloadProgress, limiter *tokenBucketLimiter, checksum checksumSpec) error {
+	partName := filename + partSuffix
+
+	f, err := os.Create(partName)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", partName, err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(size); err != nil {
+		return fmt.Errorf("pre-allocating %s to %d bytes: %w", partName, size, err)
+	}
+
+	// Every attempt re-fetches all segments from scratch (there's no partial
+	// resume across segments), so the progress counter must restart at zero
+	// too, or a retried download keeps adding to the previous attempt's count.
+	atomic.StoreInt64(&prog.done, 0)
+
+	ranges := splitIntoSegments(size, numSegments)
+	errs := make(chan error, len(ranges))
+	var wg sync.WaitGroup
+
+	for _, r := range ranges {
+		wg.Add(1)
+		go func(r segmentRange) {
+			defer wg.Done()
+
+			connSem <- struct{}{}
+			defer func() { <-connSem }()
+
+			errs <- fetchSegment(httpClient, url, opts, f, r, prog, limiter)
+		}(r)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("segmented download: %w", err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", partName, err)
+	}
+
+	if checksum.Expected != "" {
+		digest, err := computeFileChecksum(partName, checksum.Algo)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(digest, checksum.Expected) {
+			os.Remove(partName)
+			return fmt.Errorf("checksum mismatch for %s: got %s, expected %s", url, digest, checksum.Expected)
+		}
+	}
+
+	if err := os.Rename(partName, filename); err != nil {
+		return fmt.Errorf("renaming %s to %s: %w", partName, filename, err)
+	}
+
+	return nil
+}
+
+// fetchSegment downloads the byte range r of url and writes it into f at the
+// matching offset.
+func fetchSegment(httpClient *http.Client, url string, opts requestOptions, f *os.File, r segmentRange, prog *downloadProgress, limiter *tokenBucketLimiter) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request for range %d-%d: %w", r.start, r.end, err)
+	}
+
+	opts.apply(req)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.start, r.end))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("performing request for range %d-%d: %w", r.start, r.end, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("range %d-%d: server responded with status %s instead of 206", r.start, r.end, resp.Status)
+	}
+
+	body := &countingReader{Reader: throttle(resp.Body, limiter), counter: &prog.done}
+	if _, err := io.Copy(io.NewOffsetWriter(f, r.start), body); err != nil {
+		return fmt.Errorf("copying range %d-%d: %w", r.start, r.end, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// rangeServingHandler serves content from memory, honoring a "Range:
+// bytes=N-" request header with a 206 + Content-Range response, like a real
+// static file server would.
+func rangeServingHandler(content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.WriteHeader(http.StatusOK)
+			w.Write(content)
+			return
+		}
+
+		var start int64
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil || start < 0 || start > int64(len(content)) {
+			http.Error(w, "bad range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start:])
+	}
+}
+
+func TestDownloadSingleStreamResume(t *testing.T) {
+	const full = "the quick brown fox jumps over the lazy dog"
+	server := httptest.NewServer(rangeServingHandler([]byte(full)))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.txt")
+
+	// Simulate a previous attempt that got partway through.
+	const already = "the quick brown "
+	if err := os.WriteFile(filename+partSuffix, []byte(already), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prog := &downloadProgress{total: -1}
+	if err := downloadSingleStream(server.Client(), server.URL, filename, requestOptions{}, prog, nil, checksumSpec{}); err != nil {
+		t.Fatalf("downloadSingleStream: %v", err)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading result: %v", err)
+	}
+	if string(got) != full {
+		t.Errorf("downloaded content = %q, want %q", got, full)
+	}
+}
+
+func TestDownloadSingleStreamFreshDownload(t *testing.T) {
+	const full = "fresh download, no prior .part file"
+	server := httptest.NewServer(rangeServingHandler([]byte(full)))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.txt")
+
+	prog := &downloadProgress{total: -1}
+	if err := downloadSingleStream(server.Client(), server.URL, filename, requestOptions{}, prog, nil, checksumSpec{}); err != nil {
+		t.Fatalf("downloadSingleStream: %v", err)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading result: %v", err)
+	}
+	if string(got) != full {
+		t.Errorf("downloaded content = %q, want %q", got, full)
+	}
+}
+
+func TestDownloadSingleStreamRejectsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.txt")
+
+	prog := &downloadProgress{total: -1}
+	err := downloadSingleStream(server.Client(), server.URL, filename, requestOptions{}, prog, nil, checksumSpec{})
+	if err == nil {
+		t.Fatal("expected an error for a 404 response, got nil")
+	}
+
+	if _, statErr := os.Stat(filename); statErr == nil {
+		t.Error("a 404 response should not have been saved as the destination file")
+	}
+}
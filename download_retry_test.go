@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDownloadWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	const full = "eventually downloaded"
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(full))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.txt")
+
+	prog := &downloadProgress{total: -1}
+	err := downloadWithRetry(server.Client(), make(chan struct{}, 1), server.URL, filename, requestOptions{}, 5, 0, 1, prog, nil, checksumSpec{})
+	if err != nil {
+		t.Fatalf("downloadWithRetry: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("server saw %d attempts, want 3 (2 failures + 1 success)", got)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading result: %v", err)
+	}
+	if string(got) != full {
+		t.Errorf("downloaded content = %q, want %q", got, full)
+	}
+}
+
+func TestDownloadWithRetryGivesUpAfterMaxTries(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.txt")
+
+	prog := &downloadProgress{total: -1}
+	err := downloadWithRetry(server.Client(), make(chan struct{}, 1), server.URL, filename, requestOptions{}, 3, 0, 1, prog, nil, checksumSpec{})
+	if err == nil {
+		t.Fatal("expected an error once all tries are exhausted, got nil")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("server saw %d attempts, want exactly 3 (maxTries)", got)
+	}
+}
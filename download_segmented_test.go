@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadSegmentedHTTP(t *testing.T) {
+	const full = "0123456789abcdefghijklmnopqrstuvwxyz"
+	server := httptest.NewServer(rangeServingHandler([]byte(full)))
+	defer server.Close()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.txt")
+
+	prog := &downloadProgress{total: -1}
+	if err := downloadSegmented(server.Client(), make(chan struct{}, 4), server.URL, filename, requestOptions{}, int64(len(full)), 4, prog, nil, checksumSpec{}); err != nil {
+		t.Fatalf("downloadSegmented: %v", err)
+	}
+
+	got, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading result: %v", err)
+	}
+	if string(got) != full {
+		t.Errorf("assembled content = %q, want %q", got, full)
+	}
+}
+
+func TestFetchSegmentRejectsNon206(t *testing.T) {
+	// A server that ignores Range and always answers 200 with the whole
+	// body, as a server without range support would.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("whole file, ranges unsupported"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	prog := &downloadProgress{total: -1}
+	err = fetchSegment(server.Client(), server.URL, requestOptions{}, f, segmentRange{start: 0, end: 4}, prog, nil)
+	if err == nil {
+		t.Fatal("expected an error when the server doesn't honor the Range request, got nil")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitIntoSegments(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int64
+		numSegments int
+		want        []segmentRange
+	}{
+		{
+			name:        "even split",
+			size:        100,
+			numSegments: 4,
+			want: []segmentRange{
+				{start: 0, end: 24},
+				{start: 25, end: 49},
+				{start: 50, end: 74},
+				{start: 75, end: 99},
+			},
+		},
+		{
+			name:        "uneven split, remainder goes to last segment",
+			size:        10,
+			numSegments: 3,
+			want: []segmentRange{
+				{start: 0, end: 2},
+				{start: 3, end: 5},
+				{start: 6, end: 9},
+			},
+		},
+		{
+			name:        "single segment covers the whole file",
+			size:        50,
+			numSegments: 1,
+			want: []segmentRange{
+				{start: 0, end: 49},
+			},
+		},
+		{
+			name:        "more segments requested than bytes available",
+			size:        3,
+			numSegments: 8,
+			want: []segmentRange{
+				{start: 0, end: 0},
+				{start: 1, end: 1},
+				{start: 2, end: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitIntoSegments(tt.size, tt.numSegments)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitIntoSegments(%d, %d) = %+v, want %+v", tt.size, tt.numSegments, got, tt.want)
+			}
+
+			// Every split must fully and exactly cover [0, size) with no gaps
+			// or overlaps, regardless of how numSegments divides size.
+			var next int64
+			for _, r := range got {
+				if r.start != next {
+					t.Fatalf("gap/overlap before segment %+v: expected start %d", r, next)
+				}
+				next = r.end + 1
+			}
+			if tt.size > 0 && next != tt.size {
+				t.Fatalf("segments cover up to %d, want %d", next, tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Target describes one download entry in a manifest.
+type Target struct {
+	URL          string
+	Dest         string
+	Rename       string
+	ExpectedSize int64
+	SHA256       string
+	MD5          string
+	Referer      string
+	Headers      map[string]string
+	OS           []string
+	Arch         []string
+}
+
+// manifestDefaults is a manifest's top-level "defaults" block, applied to
+// every target that doesn't override them.
+type manifestDefaults struct {
+	UserAgent   string
+	Wait        int
+	Connections int
+}
+
+// matchesHostPlatform reports whether t should be downloaded on this host,
+// based on its (optional) os/arch filters.
+func (t Target) matchesHostPlatform() bool {
+	if len(t.OS) > 0 && !containsFold(t.OS, runtime.GOOS) {
+		return false
+	}
+	if len(t.Arch) > 0 && !containsFold(t.Arch, runtime.GOARCH) {
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadManifestTree reads the manifest at path, recursively resolving its
+// "refer" field (relative to path's directory), and returns the combined
+// defaults and targets. Referred-to targets come before path's own targets;
+// path's own defaults take priority over the referred manifest's.
+func loadManifestTree(path string, visited map[string]bool) (manifestDefaults, []Target, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return manifestDefaults{}, nil, fmt.Errorf("resolving path %s: %w", path, err)
+	}
+	if visited[absPath] {
+		return manifestDefaults{}, nil, fmt.Errorf("circular manifest reference at %s", path)
+	}
+	visited[absPath] = true
+
+	defaults, refer, targets, err := parseManifestFile(path)
+	if err != nil {
+		return manifestDefaults{}, nil, err
+	}
+
+	if refer == "" {
+		return defaults, targets, nil
+	}
+
+	referPath := refer
+	if !filepath.IsAbs(referPath) {
+		referPath = filepath.Join(filepath.Dir(path), referPath)
+	}
+
+	referDefaults, referTargets, err := loadManifestTree(referPath, visited)
+	if err != nil {
+		return manifestDefaults{}, nil, fmt.Errorf("loading manifest referred to by %s: %w", path, err)
+	}
+
+	return mergeDefaults(referDefaults, defaults), append(referTargets, targets...), nil
+}
+
+// parseManifestFile reads and parses a single manifest file (without
+// resolving "refer"), dispatching on its extension.
+func parseManifestFile(path string) (defaults manifestDefaults, refer string, targets []Target, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return manifestDefaults{}, "", nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var raw map[string]interface{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return manifestDefaults{}, "", nil, fmt.Errorf("parsing %s as JSON: %w", path, err)
+		}
+	case ".yaml", ".yml":
+		doc, err := parseYAMLLite(data)
+		if err != nil {
+			return manifestDefaults{}, "", nil, fmt.Errorf("parsing %s as YAML: %w", path, err)
+		}
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return manifestDefaults{}, "", nil, fmt.Errorf("parsing %s: expected a top-level mapping", path)
+		}
+		raw = m
+	default:
+		return manifestDefaults{}, "", nil, fmt.Errorf("unrecognized manifest extension for %s: expected .json, .yaml, or .yml", path)
+	}
+
+	defaults = manifestDefaultsFromMap(asMap(raw["defaults"]))
+	refer, _ = raw["refer"].(string)
+
+	rawTargets, _ := raw["targets"].([]interface{})
+	for _, rt := range rawTargets {
+		targets = append(targets, targetFromMap(asMap(rt)))
+	}
+
+	return defaults, refer, targets, nil
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func manifestDefaultsFromMap(m map[string]interface{}) manifestDefaults {
+	var d manifestDefaults
+	d.UserAgent = stringField(m, "user_agent")
+	d.Wait = intField(m, "wait")
+	d.Connections = intField(m, "connections")
+	return d
+}
+
+func targetFromMap(m map[string]interface{}) Target {
+	var t Target
+	t.URL = stringField(m, "url")
+	t.Dest = stringField(m, "dest")
+	t.Rename = stringField(m, "rename")
+	t.ExpectedSize = int64(intField(m, "size"))
+	t.SHA256 = stringField(m, "sha256")
+	t.MD5 = stringField(m, "md5")
+	t.Referer = stringField(m, "referer")
+	t.OS = stringListField(m, "os")
+	t.Arch = stringListField(m, "arch")
+
+	if rawHeaders := asMap(m["headers"]); rawHeaders != nil {
+		t.Headers = make(map[string]string, len(rawHeaders))
+		for k, v := range rawHeaders {
+			t.Headers[k] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	return t
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	if m == nil {
+		return ""
+	}
+	s, _ := m[key].(string)
+	return s
+}
+
+func intField(m map[string]interface{}, key string) int {
+	if m == nil {
+		return 0
+	}
+	switch v := m[key].(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+func stringListField(m map[string]interface{}, key string) []string {
+	if m == nil {
+		return nil
+	}
+	items, _ := m[key].([]interface{})
+	if items == nil {
+		return nil
+	}
+
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		list = append(list, fmt.Sprintf("%v", item))
+	}
+	return list
+}
+
+// mergeDefaults combines a referred-to manifest's defaults with the
+// referring manifest's own (which take priority field-by-field).
+func mergeDefaults(base, override manifestDefaults) manifestDefaults {
+	merged := base
+	if override.UserAgent != "" {
+		merged.UserAgent = override.UserAgent
+	}
+	if override.Wait > 0 {
+		merged.Wait = override.Wait
+	}
+	if override.Connections > 0 {
+		merged.Connections = override.Connections
+	}
+	return merged
+}
+
+// runManifest downloads every applicable target described by the manifest
+// at manifestPath, reporting per-target failures without aborting the rest
+// of the batch.
+func runManifest(httpClient *http.Client, connSem chan struct{}, progress *progressManager, rateLimiter *rateLimiterSource, manifestPath string) error {
+	defaults, targets, err := loadManifestTree(manifestPath, make(map[string]bool))
+	if err != nil {
+		return err
+	}
+
+	numFiles := *connections
+	if defaults.Connections > 0 {
+		numFiles = defaults.Connections
+	}
+
+	userAgent := *customAgent
+	if userAgent == "" {
+		userAgent = defaults.UserAgent
+	}
+
+	wait := *waitTime
+	if defaults.Wait > 0 {
+		wait = defaults.Wait
+	}
+
+	fileSem := make(chan struct{}, numFiles)
+	var wg sync.WaitGroup
+	first := true
+
+	for _, target := range targets {
+		if !target.matchesHostPlatform() {
+			fmt.Fprintf(os.Stderr, "skipping %s: not applicable to %s/%s\n", target.URL, runtime.GOOS, runtime.GOARCH)
+			continue
+		}
+
+		if !first {
+			simpleWaitFunc(wait)
+		}
+		first = false
+
+		wg.Add(1)
+		go func(target Target) {
+			fileSem <- struct{}{}
+			defer func() { <-fileSem }()
+			defer wg.Done()
+
+			if err := processManifestTarget(httpClient, connSem, progress, rateLimiter, target, userAgent); err != nil {
+				fmt.Fprintf(os.Stderr, "error processing target %s: %v\n", target.URL, err)
+			}
+		}(target)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// processManifestTarget downloads a single manifest target, then verifies
+// it against any expected size/checksum. On verification failure, the
+// downloaded file is deleted.
+func processManifestTarget(httpClient *http.Client, connSem chan struct{}, progress *progressManager, rateLimiter *rateLimiterSource, target Target, userAgent string) error {
+	destDir := target.Dest
+	if destDir == "" {
+		destDir = "."
+	}
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return fmt.Errorf("creating destination directory %s: %w", destDir, err)
+	}
+
+	filename := target.Rename
+	if filename == "" {
+		filename = getNameFromUrl(target.URL, !*overwriteExisting)
+	}
+	fullPath := filepath.Join(destDir, filename)
+
+	opts := requestOptions{userAgent: userAgent, referer: target.Referer, headers: target.Headers}
+
+	prog := progress.Register(target.URL, target.ExpectedSize)
+	defer progress.Unregister(prog)
+
+	if err := downloadWithRetry(httpClient, connSem, target.URL, fullPath, opts, *tries, *retryWait, *segments, prog, rateLimiter.Get(), target.checksumSpec()); err != nil {
+		return fmt.Errorf("downloading: %w", err)
+	}
+
+	if err := verifyTargetSize(fullPath, target); err != nil {
+		os.Remove(fullPath)
+		return fmt.Errorf("verification failed, removed %s: %w", fullPath, err)
+	}
+
+	if *quarantine {
+		if err := setQuarantineAttribute(fullPath, target.URL); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+
+	absPath, err := filepath.Abs(fullPath)
+	if err != nil {
+		return fmt.Errorf("getting absolute path of %s: %w", fullPath, err)
+	}
+	fmt.Fprintf(os.Stderr, "%s\n -> %s\n", target.URL, absPath)
+
+	return nil
+}
+
+// checksumSpec turns a target's sha256/md5 fields into the checksumSpec
+// downloadWithRetry verifies during the download itself (via downloadSingleStream's
+// io.TeeReader, for the common fresh-download case), instead of a second
+// full read pass afterward. sha256 takes priority if both are set.
+func (t Target) checksumSpec() checksumSpec {
+	if t.SHA256 != "" {
+		return checksumSpec{Algo: "sha256", Expected: t.SHA256}
+	}
+	if t.MD5 != "" {
+		return checksumSpec{Algo: "md5", Expected: t.MD5}
+	}
+	return checksumSpec{}
+}
+
+// verifyTargetSize checks a completed download's size against target's
+// expected size, if set. The checksum itself is already verified by
+// downloadWithRetry, via the checksumSpec built from target.
+func verifyTargetSize(path string, target Target) error {
+	if target.ExpectedSize <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat: %w", err)
+	}
+	if info.Size() != target.ExpectedSize {
+		return fmt.Errorf("size mismatch: got %d bytes, expected %d", info.Size(), target.ExpectedSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressTickInterval is how often the progress display redraws or, in
+// plain mode, prints a new status line.
+const progressTickInterval = 500 * time.Millisecond
+
+// countingReader wraps an io.Reader and tallies every byte that passes
+// through it into counter, using atomic operations so it can be read
+// concurrently by the progress display while being written to by the
+// download goroutine.
+type countingReader struct {
+	io.Reader
+	counter *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		atomic.AddInt64(r.counter, int64(n))
+	}
+	return n, err
+}
+
+// downloadProgress tracks the progress of a single URL's download. total is
+// -1 until the size becomes known (or stays -1 if it never does).
+type downloadProgress struct {
+	url   string
+	total int64 // atomic; -1 means unknown
+	done  int64 // atomic
+}
+
+// SetTotal records the total size of the download once it's known.
+func (p *downloadProgress) SetTotal(total int64) {
+	atomic.StoreInt64(&p.total, total)
+}
+
+// progressManager owns the set of in-flight downloadProgress handles and,
+// unless its mode is "none", periodically renders them.
+type progressManager struct {
+	mode string // "bar", "plain", or "none"
+
+	mu     sync.Mutex
+	active []*downloadProgress
+
+	start    time.Time
+	stopCh   chan struct{}
+	stopped  sync.WaitGroup
+	lastLine int // number of lines drawn in the previous bar-mode tick
+
+	rateLimit float64 // configured throttle, bytes/sec; 0 means unlimited
+}
+
+// newProgressManager builds a manager for the given mode. Callers that
+// resolved "auto" to a concrete mode should pass that concrete mode here.
+func newProgressManager(mode string) *progressManager {
+	return &progressManager{mode: mode, start: time.Now()}
+}
+
+// Register starts tracking a new download and returns its handle. total may
+// be -1 if the size isn't known yet; call SetTotal on the handle once it is.
+func (m *progressManager) Register(url string, total int64) *downloadProgress {
+	p := &downloadProgress{url: url, total: total}
+
+	m.mu.Lock()
+	m.active = append(m.active, p)
+	m.mu.Unlock()
+
+	return p
+}
+
+// SetRateLimit records the configured throttle so the aggregate bar can
+// display it alongside actual throughput. 0 means unlimited.
+func (m *progressManager) SetRateLimit(bytesPerSec float64) {
+	m.rateLimit = bytesPerSec
+}
+
+// Unregister stops tracking a finished (or abandoned) download.
+func (m *progressManager) Unregister(p *downloadProgress) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, other := range m.active {
+		if other == p {
+			m.active = append(m.active[:i], m.active[i+1:]...)
+			break
+		}
+	}
+}
+
+// Start begins the periodic display, if the manager's mode calls for one.
+func (m *progressManager) Start() {
+	if m.mode == "none" {
+		return
+	}
+
+	m.stopCh = make(chan struct{})
+	m.stopped.Add(1)
+
+	go func() {
+		defer m.stopped.Done()
+
+		ticker := time.NewTicker(progressTickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.render()
+			case <-m.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the periodic display and, in bar mode, leaves the cursor below
+// the last drawn frame instead of on top of it.
+func (m *progressManager) Stop() {
+	if m.mode == "none" {
+		return
+	}
+
+	close(m.stopCh)
+	m.stopped.Wait()
+
+	if m.mode == "bar" && m.lastLine > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func (m *progressManager) render() {
+	m.mu.Lock()
+	snapshot := make([]*downloadProgress, len(m.active))
+	copy(snapshot, m.active)
+	m.mu.Unlock()
+
+	switch m.mode {
+	case "bar":
+		m.renderBars(snapshot)
+	case "plain":
+		m.renderPlain(snapshot)
+	}
+}
+
+func (m *progressManager) renderBars(snapshot []*downloadProgress) {
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].url < snapshot[j].url })
+
+	var totalDone, totalKnown int64
+	lines := make([]string, 0, len(snapshot)+1)
+
+	for _, p := range snapshot {
+		done := atomic.LoadInt64(&p.done)
+		total := atomic.LoadInt64(&p.total)
+
+		totalDone += done
+		if total > 0 {
+			totalKnown += total
+		}
+
+		lines = append(lines, fmt.Sprintf("%s %s", progressBar(done, total), truncateMiddle(p.url, 60)))
+	}
+
+	elapsed := time.Since(m.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(totalDone) / elapsed
+	}
+	lines = append(lines, fmt.Sprintf("%s total: %s%s", progressBar(totalDone, totalKnown), formatRate(rate), m.rateLimitSuffix()))
+
+	// Move the cursor back up over the previous frame and clear each line
+	// before drawing the new one.
+	if m.lastLine > 0 {
+		fmt.Fprintf(os.Stderr, "\033[%dA", m.lastLine)
+	}
+	for _, line := range lines {
+		fmt.Fprintf(os.Stderr, "\033[K%s\n", line)
+	}
+
+	m.lastLine = len(lines)
+}
+
+func (m *progressManager) renderPlain(snapshot []*downloadProgress) {
+	var totalDone int64
+	for _, p := range snapshot {
+		totalDone += atomic.LoadInt64(&p.done)
+	}
+
+	elapsed := time.Since(m.start).Seconds()
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(totalDone) / elapsed
+	}
+
+	fmt.Fprintf(os.Stderr, "progress: %d file(s) active, %s downloaded, %s%s\n", len(snapshot), formatBytes(totalDone), formatRate(rate), m.rateLimitSuffix())
+}
+
+// rateLimitSuffix renders " (limit X/s)" if a throttle is configured, or ""
+// if downloads are unlimited.
+func (m *progressManager) rateLimitSuffix() string {
+	if m.rateLimit <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (limit %s)", formatRate(m.rateLimit))
+}
+
+// progressBar renders a fixed-width textual bar. If total is unknown (<= 0),
+// it renders just the raw byte count instead of a percentage bar.
+func progressBar(done, total int64) string {
+	const width = 30
+
+	if total <= 0 {
+		return fmt.Sprintf("[%s] %s", strings.Repeat("?", width), formatBytes(done))
+	}
+
+	frac := float64(done) / float64(total)
+	if frac > 1 {
+		frac = 1
+	}
+
+	filled := int(frac * float64(width))
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+
+	return fmt.Sprintf("[%s] %3.0f%% %s/%s", bar, frac*100, formatBytes(done), formatBytes(total))
+}
+
+// formatBytes renders n as a human-readable byte count.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatRate renders a bytes-per-second value as a human-readable rate.
+func formatRate(bytesPerSec float64) string {
+	return formatBytes(int64(bytesPerSec)) + "/s"
+}
+
+// truncateMiddle shortens s to at most width characters by cutting out its
+// middle, so long URLs stay readable on one line.
+func truncateMiddle(s string, width int) string {
+	if len(s) <= width {
+		return s
+	}
+
+	half := (width - 3) / 2
+	return s[:half] + "..." + s[len(s)-half:]
+}
+
+// isTerminal reports whether f is connected to a terminal, used to decide
+// what "-progress=auto" resolves to.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,28 @@
+//go:build darwin
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// quarantineAttr is the extended attribute macOS's Gatekeeper and Finder
+// check to warn before opening a file downloaded from the internet, same as
+// browsers set on their downloads.
+const quarantineAttr = "com.apple.quarantine"
+
+// setQuarantineAttribute records sourceURL on path's com.apple.quarantine
+// attribute, so Gatekeeper treats it like a browser download.
+//
+// Go's stdlib syscall package doesn't expose Setxattr on darwin (unlike
+// linux), and this module has no external dependencies to pull in
+// golang.org/x/sys/unix for it, so this shells out to the xattr(1) tool that
+// ships with macOS instead.
+func setQuarantineAttribute(path, sourceURL string) error {
+	value := fmt.Sprintf("0081;00000000;shitty_wget;;%s", sourceURL)
+	if err := exec.Command("xattr", "-w", quarantineAttr, value, path).Run(); err != nil {
+		return fmt.Errorf("setting %s xattr on %s: %w", quarantineAttr, path, err)
+	}
+	return nil
+}
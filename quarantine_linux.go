@@ -0,0 +1,27 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// quarantineAttr is the extended attribute Linux desktop environments (via
+// the freedesktop.org "shared MIME info" convention) check to show a
+// downloaded file's origin.
+const quarantineAttr = "user.xdg.origin.url"
+
+// setQuarantineAttribute records sourceURL on path as an extended attribute,
+// so file managers that understand it can warn before opening the file. On a
+// filesystem without extended attribute support it does nothing.
+func setQuarantineAttribute(path, sourceURL string) error {
+	err := syscall.Setxattr(path, quarantineAttr, []byte(sourceURL), 0)
+	if err == syscall.ENOTSUP {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("setting %s xattr on %s: %w", quarantineAttr, path, err)
+	}
+	return nil
+}
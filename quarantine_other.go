@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package main
+
+// setQuarantineAttribute is a portable no-op on platforms without a known
+// quarantine-style extended attribute, so -quarantine can be left on
+// unconditionally without failing the download.
+func setQuarantineAttribute(path, sourceURL string) error {
+	return nil
+}
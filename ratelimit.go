@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucketLimiter is a simple token-bucket rate limiter: tokens accrue at
+// ratePerSec, up to a capacity equal to one second's worth, and WaitN blocks
+// until enough tokens are available to spend.
+//
+// This is hand-rolled instead of using golang.org/x/time/rate because the
+// repo has no go.mod anywhere in this series and so can't pull in anything
+// outside the standard library (same tradeoff as quarantine_darwin.go's use
+// of the xattr(1) binary instead of golang.org/x/sys/unix).
+type tokenBucketLimiter struct {
+	mu         sync.Mutex
+	ratePerSec float64
+	capacity   float64
+	tokens     float64
+	last       time.Time
+}
+
+// newTokenBucketLimiter builds a limiter capped at ratePerSec bytes/sec,
+// starting with a full bucket so the first burst isn't throttled.
+func newTokenBucketLimiter(ratePerSec float64) *tokenBucketLimiter {
+	return &tokenBucketLimiter{
+		ratePerSec: ratePerSec,
+		capacity:   ratePerSec,
+		tokens:     ratePerSec,
+		last:       time.Now(),
+	}
+}
+
+// waitIncrement bounds how long WaitN sleeps between refills, so it spends
+// whatever tokens are available in bounded steps instead of waiting for the
+// full amount up front, which a bucket capacity smaller than n (e.g. a
+// 4-32KB HTTP read against a sub-KB/s limit) would never satisfy.
+const waitIncrement = 250 * time.Millisecond
+
+// WaitN blocks until n tokens have been spent, spending whatever is
+// available on each pass and sleeping in bounded increments for the rest, so
+// it always makes progress regardless of how n compares to the bucket's
+// capacity.
+func (l *tokenBucketLimiter) WaitN(n int) {
+	want := float64(n)
+
+	for want > 0 {
+		l.mu.Lock()
+		l.refill()
+
+		take := want
+		if take > l.tokens {
+			take = l.tokens
+		}
+		l.tokens -= take
+		want -= take
+		ratePerSec := l.ratePerSec
+		l.mu.Unlock()
+
+		if want <= 0 {
+			return
+		}
+
+		wait := time.Duration(want / ratePerSec * float64(time.Second))
+		if wait > waitIncrement {
+			wait = waitIncrement
+		}
+		time.Sleep(wait)
+	}
+}
+
+// refill must be called with l.mu held.
+func (l *tokenBucketLimiter) refill() {
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.ratePerSec
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+}
+
+// limitedReader throttles an io.Reader through a tokenBucketLimiter: every
+// Read call waits for as many tokens as bytes it returned before handing
+// them back to the caller, so throughput through r stays under the limit.
+type limitedReader struct {
+	io.Reader
+	limiter *tokenBucketLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && r.limiter != nil {
+		r.limiter.WaitN(n)
+	}
+	return n, err
+}
+
+// throttle wraps r in a limitedReader if limiter is non-nil, otherwise
+// returns r unchanged.
+func throttle(r io.Reader, limiter *tokenBucketLimiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{Reader: r, limiter: limiter}
+}
+
+// rateLimiterSource hands out the tokenBucketLimiter a download worker
+// should throttle through: the same shared instance for every worker, or a
+// fresh one per worker, depending on how -limit-rate-per-conn was set.
+type rateLimiterSource struct {
+	shared      *tokenBucketLimiter
+	perConnRate float64
+}
+
+// newRateLimiterSource builds a source from the raw -limit-rate flag value
+// (e.g. "500k", "2M", or "" for no limit) and whether -limit-rate-per-conn
+// was set.
+func newRateLimiterSource(rawRate string, perConn bool) (*rateLimiterSource, error) {
+	if rawRate == "" {
+		return &rateLimiterSource{}, nil
+	}
+
+	rate, err := parseByteRate(rawRate)
+	if err != nil {
+		return nil, err
+	}
+
+	if perConn {
+		return &rateLimiterSource{perConnRate: rate}, nil
+	}
+	return &rateLimiterSource{shared: newTokenBucketLimiter(rate)}, nil
+}
+
+// Get returns the limiter a new download worker should use, or nil if
+// throttling is disabled. Safe to call on a nil source.
+func (s *rateLimiterSource) Get() *tokenBucketLimiter {
+	if s == nil {
+		return nil
+	}
+	if s.shared != nil {
+		return s.shared
+	}
+	if s.perConnRate > 0 {
+		return newTokenBucketLimiter(s.perConnRate)
+	}
+	return nil
+}
+
+// CurrentRate returns the configured limit in bytes/sec, or 0 if throttling
+// is disabled.
+func (s *rateLimiterSource) CurrentRate() float64 {
+	if s == nil {
+		return 0
+	}
+	if s.shared != nil {
+		return s.shared.ratePerSec
+	}
+	return s.perConnRate
+}
+
+// parseByteRate parses a byte-rate string like "500k" or "2M" into a plain
+// bytes/sec float. A bare number is interpreted as bytes/sec.
+func parseByteRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty rate")
+	}
+
+	multiplier := 1.0
+	suffix := s[len(s)-1]
+	switch suffix {
+	case 'k', 'K':
+		multiplier = 1024
+		s = s[:len(s)-1]
+	case 'm', 'M':
+		multiplier = 1024 * 1024
+		s = s[:len(s)-1]
+	case 'g', 'G':
+		multiplier = 1024 * 1024 * 1024
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: must be positive", s)
+	}
+
+	return value * multiplier, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWaitNLargerThanCapacity reproduces a hang where WaitN(n) for an n
+// larger than the bucket's capacity (e.g. a 4KB HTTP read against a
+// 1024B/s limiter, whose capacity is also 1024) never returned, because
+// l.tokens could never reach n under the old all-or-nothing spend.
+func TestWaitNLargerThanCapacity(t *testing.T) {
+	limiter := newTokenBucketLimiter(1024) // capacity == 1024 tokens
+
+	done := make(chan struct{})
+	go func() {
+		limiter.WaitN(4096) // 4x the bucket's capacity
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("WaitN did not return for n > capacity; limiter is stuck")
+	}
+}
+
+func TestWaitNRoughRate(t *testing.T) {
+	const rate = 2000.0 // bytes/sec
+	limiter := newTokenBucketLimiter(rate)
+	limiter.tokens = 0 // force every byte to be paced by the rate, no burst credit
+
+	start := time.Now()
+	limiter.WaitN(int(rate)) // should take about 1 second
+	elapsed := time.Since(start)
+
+	if elapsed < 800*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Fatalf("WaitN(%v) at %v bytes/sec took %v, want ~1s", rate, rate, elapsed)
+	}
+}
@@ -15,6 +15,11 @@ import (
 	"time"
 )
 
+// partSuffix is appended to the destination filename while a download is in
+// progress, so an interrupted download can be resumed and an unfinished one is
+// never mistaken for a complete file.
+const partSuffix = ".part"
+
 // FileNameList is a list of filenames.
 //
 // It implements the flag.Value interface for use with the flag package.
@@ -45,6 +50,17 @@ var listUserAgents = flag.Bool("list-agents", false, "list internally avaiable U
 var customAgent = flag.String("custom-agent", "", "set a custom `User-Agent` string; overrides random-agent")
 var waitTime = flag.Int("wait", 0, "wait an amount of `seconds` between individual connections; numbers < 0 will be interpreted as 0; can be used in conjunction with random-wait")
 var randomWait = flag.Bool("random-wait", false, "instead of waiting a fixed amount of time, wait a random amount of seconds between 0 to the number specified by wait; not much will happen if wait is not specified")
+var tries = flag.Int("tries", 3, "number of `attempts` to make for each download before giving up; numbers <= 0 will be interpreted as 1")
+var retryWait = flag.Int("retry-wait", 1, "base number of `seconds` to wait before retrying a failed download; doubles after each failed attempt")
+var segments = flag.Int("segments", 1, "number of `segments` to split each file into for concurrent range downloads; numbers <= 1 disable segmentation, and segmentation is skipped for servers that don't advertise range support")
+var maxConnections = flag.Int("max-connections", 4, "maximum total number of `connections` open at once across all segmented downloads; numbers <= 0 will be interpreted as the value of -segments")
+var progressMode = flag.String("progress", "auto", "progress display `mode`: auto, bar, plain, or none; auto picks bar when stderr is a terminal and plain otherwise")
+var manifestFile = flag.String("manifest", "", "load targets from a manifest `file` (.json, .yaml, or .yml) instead of -i/URL arguments")
+var limitRate = flag.String("limit-rate", "", "limit aggregate download `rate` across all connections, e.g. 500k or 2M; empty disables throttling")
+var limitRatePerConn = flag.Bool("limit-rate-per-conn", false, "apply -limit-rate independently to each connection instead of as a shared aggregate limit")
+var checksumAlgo = flag.String("checksum-algo", "sha256", "checksum `algorithm` used to verify downloads against -checksum-file hashes: sha256 or md5; a URL's #sha256=... fragment is always checked as sha256")
+var checksumFile = flag.String("checksum-file", "", "verify downloaded files against hashes listed in `file`, a sha256sum(1)-style sidecar of \"HASH  filename\" lines")
+var quarantine = flag.Bool("quarantine", false, "mark downloaded files with an extended attribute recording their source URL, like a browser download (com.apple.quarantine on macOS, user.xdg.origin.url on Linux, no-op elsewhere)")
 var urlFiles FileNameList
 
 func main() {
@@ -64,6 +80,50 @@ func main() {
 	if *waitTime < 0 {
 		*waitTime = 0
 	}
+	if *tries <= 0 {
+		*tries = 1
+	}
+	if *retryWait < 0 {
+		*retryWait = 0
+	}
+	if *segments <= 0 {
+		*segments = 1
+	}
+	if *maxConnections <= 0 {
+		*maxConnections = *segments
+	}
+
+	resolvedProgressMode, err := resolveProgressMode(*progressMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	rateLimiter, err := newRateLimiterSource(*limitRate, *limitRatePerConn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var checksumSums map[string]string
+	if *checksumFile != "" {
+		checksumSums, err = loadChecksumFile(*checksumFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Resolve before the -dest chdir below, so a relative -manifest path is
+	// read from the original working directory instead of inside -dest.
+	if *manifestFile != "" {
+		absManifestFile, err := filepath.Abs(*manifestFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error resolving manifest path %s: %v\n", *manifestFile, err)
+			os.Exit(1)
+		}
+		*manifestFile = absManifestFile
+	}
 
 	if *listUserAgents {
 		fmt.Println(strings.Join(UserAgents, "\n"))
@@ -71,7 +131,7 @@ func main() {
 	}
 
 	// If invoked with no arguments, consider it a valid way to ask for help
-	if len(urlFiles) == 0 && len(flag.Args()) == 0 {
+	if *manifestFile == "" && len(urlFiles) == 0 && len(flag.Args()) == 0 {
 		flag.Usage()
 		os.Exit(0)
 	}
@@ -114,9 +174,18 @@ func main() {
 
 	httpClient := &http.Client{}
 
+	// Two-level scheduler: semaphore bounds how many files are in flight at
+	// once, while connSem bounds the total number of HTTP connections open
+	// across all of those files' segments combined.
 	semaphore := make(chan struct{}, *connections)
+	connSem := make(chan struct{}, *maxConnections)
 	var wg sync.WaitGroup
 
+	progress := newProgressManager(resolvedProgressMode)
+	progress.SetRateLimit(rateLimiter.CurrentRate())
+	progress.Start()
+	defer progress.Stop()
+
 	if *destDir != "." {
 		if !fileExists(*destDir) {
 			err := os.MkdirAll(*destDir, os.ModePerm)
@@ -133,6 +202,14 @@ func main() {
 		}
 	}
 
+	if *manifestFile != "" {
+		if err := runManifest(httpClient, connSem, progress, rateLimiter, *manifestFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error processing manifest %s: %v\n", *manifestFile, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	urlScanner := bufio.NewReader(io.MultiReader(urlReaders...))
 	firstLoop := true
 	for {
@@ -170,45 +247,37 @@ func main() {
 			defer func() { <-semaphore }()
 			defer wg.Done()
 
-			req, errWorker := http.NewRequest("GET", url, nil)
-			if errWorker != nil {
-				fmt.Fprintf(os.Stderr, "error creating request for %s: %v\n", url, errWorker)
-				return
-			}
+			filename := getNameFromUrl(url, !*overwriteExisting)
 
-			if *customAgent != "" {
-				req.Header.Set("User-Agent", *customAgent)
+			checksum := checksumFragment(url)
+			if checksum.Expected == "" {
+				if expected, ok := checksumSums[filename]; ok {
+					checksum = checksumSpec{Algo: *checksumAlgo, Expected: expected}
+				}
 			}
 
-			resp, errWorker := httpClient.Do(req)
+			prog := progress.Register(url, -1)
+			defer progress.Unregister(prog)
+
+			errWorker := downloadWithRetry(httpClient, connSem, url, filename, requestOptions{userAgent: *customAgent}, *tries, *retryWait, *segments, prog, rateLimiter.Get(), checksum)
 			if errWorker != nil {
 				fmt.Fprintf(os.Stderr, "error downloading %s: %v\n", url, errWorker)
 				return
 			}
-			defer resp.Body.Close()
-
-			filename := getNameFromUrl(url, !*overwriteExisting)
 
-			f, errWorker := os.Create(filename)
-			if errWorker != nil {
-				fmt.Fprintf(os.Stderr, "error creating file %s: %v\n", filename, errWorker)
-				return
+			if *quarantine {
+				if err := setQuarantineAttribute(filename, url); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				}
 			}
-			defer f.Close()
 
-			filename, errWorker = filepath.Abs(filename)
+			absFilename, errWorker := filepath.Abs(filename)
 			if errWorker != nil {
 				fmt.Fprintf(os.Stderr, "error while getting absolute path of %s: %v\n", filename, errWorker)
 				return
 			}
 
-			_, errWorker = io.Copy(f, resp.Body)
-			if errWorker != nil {
-				fmt.Fprintf(os.Stderr, "error during download %s to %s: %v\n", url, filename, errWorker)
-				return
-			}
-
-			fmt.Fprintf(os.Stderr, "%s\n -> %s\n", url, filename)
+			fmt.Fprintf(os.Stderr, "%s\n -> %s\n", url, absFilename)
 		}(url)
 	}
 
@@ -228,6 +297,23 @@ func randomWaitFunc(waitTime int) {
 	time.Sleep(time.Duration(rand.Intn(waitTime+1)) * time.Second)
 }
 
+// resolveProgressMode turns the raw -progress flag value into a concrete
+// "bar", "plain", or "none" mode, resolving "auto" based on whether stderr
+// is a terminal.
+func resolveProgressMode(mode string) (string, error) {
+	switch mode {
+	case "auto":
+		if isTerminal(os.Stderr) {
+			return "bar", nil
+		}
+		return "plain", nil
+	case "bar", "plain", "none":
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid -progress mode %q: must be one of auto, bar, plain, none", mode)
+	}
+}
+
 // fileExists is a utility function that checks if  file exists
 func fileExists(fileName string) bool {
 	_, err := os.Stat(fileName)
@@ -243,7 +329,7 @@ func fileExists(fileName string) bool {
 // Optionally, can try to create a unique filename if it detects the file already exists,
 // to avoid overwriting
 func getNameFromUrl(url string, detectRepeatedNames bool) string {
-	baseName := strings.Split(path.Base(url), "?")[0]
+	baseName := strings.Split(strings.Split(path.Base(url), "?")[0], "#")[0]
 
 	currentName := baseName
 
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements a small, dependency-free subset of YAML: block
+// mappings and sequences, inline flow sequences ("[a, b]"), quoted and bare
+// scalar strings, and integers/booleans. It's only meant for manifest.go's
+// target files, not as a general-purpose YAML parser.
+
+func countIndent(line string) int {
+	n := 0
+	for _, c := range line {
+		if c == ' ' {
+			n++
+		} else {
+			break
+		}
+	}
+	return n
+}
+
+type lineInfo struct {
+	indent int
+	text   string // trimmed of leading whitespace, no trailing newline
+}
+
+func preprocessYAML(data []byte) []lineInfo {
+	rawLines := strings.Split(string(data), "\n")
+	lines := make([]lineInfo, 0, len(rawLines))
+	for _, raw := range rawLines {
+		raw = strings.TrimRight(raw, "\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(trimmed), "#") {
+			continue
+		}
+		lines = append(lines, lineInfo{indent: countIndent(raw), text: trimmed})
+	}
+	return lines
+}
+
+type yamlParser struct {
+	lines []lineInfo
+	pos   int
+}
+
+func parseYAMLLite(data []byte) (interface{}, error) {
+	p := &yamlParser{lines: preprocessYAML(data)}
+	if len(p.lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	return p.parseBlock(p.lines[0].indent)
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+		return nil, fmt.Errorf("unexpected indentation at line %d", p.pos)
+	}
+
+	if strings.HasPrefix(p.lines[p.pos].text, "- ") || p.lines[p.pos].text == "-" {
+		return p.parseList(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseList(indent int) ([]interface{}, error) {
+	var result []interface{}
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent &&
+		(strings.HasPrefix(p.lines[p.pos].text, "- ") || p.lines[p.pos].text == "-") {
+
+		text := p.lines[p.pos].text
+		rest := strings.TrimPrefix(text, "-")
+		rest = strings.TrimPrefix(rest, " ")
+
+		if rest == "" {
+			// "-" alone on its line: value is a nested block at deeper indent.
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				val, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, val)
+			} else {
+				result = append(result, nil)
+			}
+			continue
+		}
+
+		if key, val, ok := splitKeyValue(rest); ok {
+			// "- key: value" (or "- key:" with nested block) starts an inline
+			// map; continuation lines are indented to align under "key".
+			contIndent := indent + (len(text) - len(rest))
+			m := map[string]interface{}{}
+
+			if val == "" {
+				p.pos++
+				if p.pos < len(p.lines) && p.lines[p.pos].indent > contIndent-1 && p.lines[p.pos].indent >= contIndent {
+					nested, err := p.parseBlock(p.lines[p.pos].indent)
+					if err != nil {
+						return nil, err
+					}
+					m[key] = nested
+				} else {
+					m[key] = nil
+				}
+			} else {
+				m[key] = parseScalar(val)
+				p.pos++
+			}
+
+			for p.pos < len(p.lines) && p.lines[p.pos].indent == contIndent {
+				k2, v2, err := p.parseMapEntry(contIndent)
+				if err != nil {
+					return nil, err
+				}
+				m[k2] = v2
+			}
+
+			result = append(result, m)
+			continue
+		}
+
+		// "- scalar"
+		result = append(result, parseScalar(rest))
+		p.pos++
+	}
+
+	return result, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		k, v, err := p.parseMapEntry(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+
+	return m, nil
+}
+
+// parseMapEntry consumes one "key: value" (or "key:" + nested block) entry
+// at the given indent and advances p.pos past it.
+func (p *yamlParser) parseMapEntry(indent int) (string, interface{}, error) {
+	text := p.lines[p.pos].text
+
+	key, val, ok := splitKeyValue(text)
+	if !ok {
+		return "", nil, fmt.Errorf("expected \"key: value\" at line %d, got %q", p.pos, text)
+	}
+
+	p.pos++
+
+	if val != "" {
+		return key, parseScalar(val), nil
+	}
+
+	// No inline value: if the next line is more indented, it's this key's
+	// nested block; otherwise the value is null.
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		nested, err := p.parseBlock(p.lines[p.pos].indent)
+		if err != nil {
+			return "", nil, err
+		}
+		return key, nested, nil
+	}
+
+	return key, nil, nil
+}
+
+// splitKeyValue splits "key: value" into key and value (value is "" if the
+// line is just "key:"). ok is false if text isn't a key: value line at all
+// (e.g. a flow-style list scalar).
+func splitKeyValue(text string) (key, val string, ok bool) {
+	idx := strings.Index(text, ":")
+	if idx == -1 {
+		return "", "", false
+	}
+	// Guard against colons inside URLs when there's no space after them,
+	// e.g. "url: https://example.com" is fine (space after ":"), but a bare
+	// scalar like "https://example.com" has no recognized "key" before it.
+	if idx+1 < len(text) && text[idx+1] != ' ' {
+		return "", "", false
+	}
+
+	key = strings.TrimSpace(text[:idx])
+	val = strings.TrimSpace(text[idx+1:])
+	return key, val, true
+}
+
+// parseScalar converts a scalar token into a string, int64, bool, or (for
+// "[a, b, c]") a []interface{} whose items are each parsed as a scalar.
+func parseScalar(s string) interface{} {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+		var items []interface{}
+		if strings.TrimSpace(inner) != "" {
+			for _, part := range strings.Split(inner, ",") {
+				items = append(items, parseScalar(strings.TrimSpace(part)))
+			}
+		}
+		return items
+	}
+
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+
+	if s == "true" {
+		return true
+	}
+	if s == "false" {
+		return false
+	}
+
+	return s
+}
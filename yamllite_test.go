@@ -0,0 +1,156 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAMLLite(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{
+			name:  "flat map",
+			input: "a: 1\nb: two\nc: true\n",
+			want: map[string]interface{}{
+				"a": int64(1),
+				"b": "two",
+				"c": true,
+			},
+		},
+		{
+			name: "nested map",
+			input: "defaults:\n" +
+				"  user_agent: bot\n" +
+				"  wait: 2\n",
+			want: map[string]interface{}{
+				"defaults": map[string]interface{}{
+					"user_agent": "bot",
+					"wait":       int64(2),
+				},
+			},
+		},
+		{
+			name:  "list of scalars",
+			input: "- a\n- b\n- c\n",
+			want:  []interface{}{"a", "b", "c"},
+		},
+		{
+			name: "list of maps (inline key starts each entry)",
+			input: "- url: http://example.com/a\n" +
+				"  dest: out\n" +
+				"- url: http://example.com/b\n" +
+				"  dest: out2\n",
+			want: []interface{}{
+				map[string]interface{}{"url": "http://example.com/a", "dest": "out"},
+				map[string]interface{}{"url": "http://example.com/b", "dest": "out2"},
+			},
+		},
+		{
+			name: "list entry with nested block under bare dash",
+			input: "-\n" +
+				"  url: http://example.com/a\n" +
+				"  dest: out\n",
+			want: []interface{}{
+				map[string]interface{}{"url": "http://example.com/a", "dest": "out"},
+			},
+		},
+		{
+			name:  "flow list",
+			input: "os: [linux, darwin]\n",
+			want: map[string]interface{}{
+				"os": []interface{}{"linux", "darwin"},
+			},
+		},
+		{
+			name:  "empty flow list",
+			input: "os: []\n",
+			want: map[string]interface{}{
+				"os": []interface{}(nil),
+			},
+		},
+		{
+			name:  "quoted strings preserve colons and spaces",
+			input: "referer: \"http://example.com: odd\"\n",
+			want: map[string]interface{}{
+				"referer": "http://example.com: odd",
+			},
+		},
+		{
+			name:  "single-quoted string",
+			input: "name: 'hello world'\n",
+			want: map[string]interface{}{
+				"name": "hello world",
+			},
+		},
+		{
+			name: "map value nested under key with no inline value",
+			input: "headers:\n" +
+				"  X-Token: abc\n" +
+				"  X-Other: def\n",
+			want: map[string]interface{}{
+				"headers": map[string]interface{}{
+					"X-Token": "abc",
+					"X-Other": "def",
+				},
+			},
+		},
+		{
+			name:  "bare URL value with colon isn't mistaken for a nested key",
+			input: "url: http://example.com/a?b=c\n",
+			want: map[string]interface{}{
+				"url": "http://example.com/a?b=c",
+			},
+		},
+		{
+			name:  "comments and blank lines are ignored",
+			input: "# leading comment\na: 1\n\n# another comment\nb: 2\n",
+			want: map[string]interface{}{
+				"a": int64(1),
+				"b": int64(2),
+			},
+		},
+		{
+			name:  "empty document",
+			input: "",
+			want:  map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAMLLite([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("parseYAMLLite(%q) returned error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAMLLite(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScalar(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"42", int64(42)},
+		{"true", true},
+		{"false", false},
+		{"plain", "plain"},
+		{`"quoted"`, "quoted"},
+		{"'quoted'", "quoted"},
+		{"[a, b, c]", []interface{}{"a", "b", "c"}},
+		{"[]", []interface{}(nil)},
+	}
+
+	for _, tt := range tests {
+		got := parseScalar(tt.input)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseScalar(%q) = %#v, want %#v", tt.input, got, tt.want)
+		}
+	}
+}